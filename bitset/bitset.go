@@ -2,42 +2,93 @@
 Package bitset provides a simple implementation of a bitset using a slice of uint64 integers.
 
 A bitset is a memory-efficient data structure for storing bits (0 or 1) and is useful for representing sets of integers.
+
+A BitSet can be persisted with Serialize and Deserialize, which write the logical size followed by
+the packed words in little-endian order, or shared without copying through Bytes and FromBytes, which
+expose the words in the machine's native byte order.
 */
 package bitset
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"math/bits"
+	"unsafe"
 )
 
+// readChunkWords is the number of words Deserialize reads at a time, so that memory
+// is only allocated for data that is actually present in the input.
+const readChunkWords = 1024
+
 // BitSet represents a bitset using a slice of uint64 values.
 type BitSet struct {
+	size int
 	bits []uint64
 }
 
-// NewBitSet creates a BitSet with the given size (in bits).
+// NewBitSet creates a BitSet with the given size (in bits). A negative size is
+// treated as zero.
 func NewBitSet(size int) *BitSet {
+	if size < 0 {
+		size = 0
+	}
 	return &BitSet{
-		bits: make([]uint64, (size+63)/64),
+		size: size,
+		bits: make([]uint64, wordsFor(size)),
+	}
+}
+
+// FromBytes creates a BitSet of the given size (in bits) backed by data, which must
+// hold the words in the machine's native byte order, as returned by Bytes. When data
+// is 8-byte aligned the BitSet shares its memory, so changes to one are visible in the
+// other; otherwise data is copied.
+// It returns an error if the size is negative, if data is too short to hold size bits,
+// or if any bit past size is set.
+func FromBytes(data []byte, size int) (*BitSet, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid size: %d", size)
+	}
+	words := wordsFor(size)
+	if len(data) < words*8 {
+		return nil, fmt.Errorf("data too short: %d bytes, expected at least %d", len(data), words*8)
+	}
+	if words == 0 {
+		return &BitSet{size: size, bits: []uint64{}}, nil
+	}
+
+	var bs *BitSet
+	pointer := unsafe.Pointer(&data[0])
+	if uintptr(pointer)%unsafe.Alignof(uint64(0)) == 0 {
+		bs = &BitSet{size: size, bits: unsafe.Slice((*uint64)(pointer), words)}
+	} else {
+		bs = NewBitSet(size)
+		copy(bs.Bytes(), data)
+	}
+	if err := bs.checkUnusedBits(); err != nil {
+		return nil, err
 	}
+	return bs, nil
 }
 
 // Set sets the bit at the specified position to 1.
 func (bs *BitSet) Set(pos int) error {
-	index, offset := pos/64, pos%64
-	if index < 0 || index >= len(bs.bits) {
+	if pos < 0 || pos >= bs.size {
 		return fmt.Errorf("invalid position: %d", pos)
 	}
+	index, offset := pos/64, pos%64
 	bs.bits[index] |= 1 << offset
 	return nil
 }
 
 // Clear resets the bit at the specified position to 0.
 func (bs *BitSet) Clear(pos int) error {
-	index, offset := pos/64, pos%64
-	if index < 0 || index >= len(bs.bits) {
+	if pos < 0 || pos >= bs.size {
 		return fmt.Errorf("invalid position: %d", pos)
 	}
+	index, offset := pos/64, pos%64
 	// Create a mask with all bits set to 1, except for the bit at position `offset` which is set to 0.
 	// This mask will be used to clear the bit at the given position while leaving other bits unchanged.
 	mask := uint64(^(1 << offset))
@@ -47,10 +98,10 @@ func (bs *BitSet) Clear(pos int) error {
 
 // Test returns true if the bit at the specified position is set to 1.
 func (bs *BitSet) Test(pos int) (bool, error) {
-	index, offset := pos/64, pos%64
-	if index < 0 || index >= len(bs.bits) {
+	if pos < 0 || pos >= bs.size {
 		return false, fmt.Errorf("invalid position: %d", pos)
 	}
+	index, offset := pos/64, pos%64
 	return (bs.bits[index] & (1 << offset)) != 0, nil
 }
 
@@ -62,3 +113,214 @@ func (bs *BitSet) Count() int {
 	}
 	return count
 }
+
+// Size returns the number of bits the BitSet was created or grown to hold.
+func (bs *BitSet) Size() int {
+	return bs.size
+}
+
+// Grow extends the BitSet to hold newSize bits, preserving the existing bits.
+// It returns an error if newSize is smaller than the current size.
+func (bs *BitSet) Grow(newSize int) error {
+	if newSize < bs.size {
+		return fmt.Errorf("cannot shrink from %d to %d bits", bs.size, newSize)
+	}
+	if words := wordsFor(newSize); words > len(bs.bits) {
+		grown := make([]uint64, words)
+		copy(grown, bs.bits)
+		bs.bits = grown
+	}
+	bs.size = newSize
+	return nil
+}
+
+// Bytes returns the words of the BitSet as a byte slice in the machine's native
+// byte order, without copying. Changes to the returned slice are visible in the
+// BitSet until it is grown.
+func (bs *BitSet) Bytes() []byte {
+	if len(bs.bits) == 0 {
+		return []byte{}
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&bs.bits[0])), len(bs.bits)*8)
+}
+
+// Serialize writes the logical size of the BitSet followed by its words to w,
+// in little-endian order.
+func (bs *BitSet) Serialize(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, uint64(bs.size)); err != nil {
+		return fmt.Errorf("failed to write size: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, bs.bits); err != nil {
+		return fmt.Errorf("failed to write bits: %w", err)
+	}
+	return nil
+}
+
+// Deserialize replaces the contents of the BitSet with the data read from r, as
+// written by Serialize. On error the BitSet is left unchanged.
+func (bs *BitSet) Deserialize(r io.Reader) error {
+	var size uint64
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return fmt.Errorf("failed to read size: %w", err)
+	}
+	if size > uint64(math.MaxInt-63) {
+		return fmt.Errorf("invalid size: %d", size)
+	}
+
+	words := wordsFor(int(size))
+	data := make([]uint64, 0, min(words, readChunkWords))
+	for len(data) < words {
+		chunk := make([]uint64, min(words-len(data), readChunkWords))
+		if err := binary.Read(r, binary.LittleEndian, chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("failed to read bits: %w", err)
+		}
+		data = append(data, chunk...)
+	}
+
+	restored := &BitSet{size: int(size), bits: data}
+	if err := restored.checkUnusedBits(); err != nil {
+		return err
+	}
+	*bs = *restored
+	return nil
+}
+
+// Union returns a new BitSet with the bits set in either bitset.
+// It returns an error if the two bitsets have different sizes.
+func (bs *BitSet) Union(other *BitSet) (*BitSet, error) {
+	return bs.combine(other, or)
+}
+
+// Intersect returns a new BitSet with the bits set in both bitsets.
+// It returns an error if the two bitsets have different sizes.
+func (bs *BitSet) Intersect(other *BitSet) (*BitSet, error) {
+	return bs.combine(other, and)
+}
+
+// Difference returns a new BitSet with the bits set in bs but not in other.
+// It returns an error if the two bitsets have different sizes.
+func (bs *BitSet) Difference(other *BitSet) (*BitSet, error) {
+	return bs.combine(other, andNot)
+}
+
+// Or sets in place the bits of bs that are set in other, without allocating.
+// It returns an error if the two bitsets have different sizes, leaving bs unchanged.
+func (bs *BitSet) Or(other *BitSet) error {
+	return bs.combineInto(bs, other, or)
+}
+
+// And clears in place the bits of bs that are not set in other, without allocating.
+// It returns an error if the two bitsets have different sizes, leaving bs unchanged.
+func (bs *BitSet) And(other *BitSet) error {
+	return bs.combineInto(bs, other, and)
+}
+
+// AndNot clears in place the bits of bs that are set in other, without allocating.
+// It returns an error if the two bitsets have different sizes, leaving bs unchanged.
+func (bs *BitSet) AndNot(other *BitSet) error {
+	return bs.combineInto(bs, other, andNot)
+}
+
+// Equal returns true if both bitsets have the same size and the same bits set.
+func (bs *BitSet) Equal(other *BitSet) bool {
+	if bs.size != other.size {
+		return false
+	}
+	for i, word := range bs.bits {
+		if word != other.bits[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// NextSet returns the position of the first bit set to 1 at or after from.
+// The second return value is false if there is no such bit.
+func (bs *BitSet) NextSet(from int) (int, bool) {
+	if from < 0 {
+		from = 0
+	}
+	index := from / 64
+	if index >= len(bs.bits) {
+		return 0, false
+	}
+	// Ignore the bits before `from` in the first word.
+	word := bs.bits[index] >> (from % 64)
+	if word != 0 {
+		return from + bits.TrailingZeros64(word), true
+	}
+	for index++; index < len(bs.bits); index++ {
+		if bs.bits[index] != 0 {
+			return index*64 + bits.TrailingZeros64(bs.bits[index]), true
+		}
+	}
+	return 0, false
+}
+
+// ForEach calls fn with the position of each bit set to 1, in increasing order.
+// Iteration stops early if fn returns false.
+func (bs *BitSet) ForEach(fn func(pos int) bool) {
+	for index, word := range bs.bits {
+		for word != 0 {
+			offset := bits.TrailingZeros64(word)
+			if !fn(index*64 + offset) {
+				return
+			}
+			// Clear the lowest set bit.
+			word &= word - 1
+		}
+	}
+}
+
+// combine returns a new BitSet whose words are op applied to the words of bs and other.
+func (bs *BitSet) combine(other *BitSet, op func(a, b uint64) uint64) (*BitSet, error) {
+	result := NewBitSet(bs.size)
+	if err := bs.combineInto(result, other, op); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// combineInto stores in dst the words of op applied to the words of bs and other.
+// dst must have the same size as bs, and may be bs itself.
+func (bs *BitSet) combineInto(dst, other *BitSet, op func(a, b uint64) uint64) error {
+	if bs.size != other.size {
+		return fmt.Errorf("size mismatch: %d and %d bits", bs.size, other.size)
+	}
+	for i, word := range bs.bits {
+		dst.bits[i] = op(word, other.bits[i])
+	}
+	return nil
+}
+
+func or(a, b uint64) uint64     { return a | b }
+func and(a, b uint64) uint64    { return a & b }
+func andNot(a, b uint64) uint64 { return a &^ b }
+
+// checkUnusedBits returns an error if any bit past the size of the BitSet is set.
+// Such bits can only come from external data, since Set rejects their positions.
+func (bs *BitSet) checkUnusedBits() error {
+	if bs.size%64 == 0 {
+		return nil
+	}
+	if bs.bits[len(bs.bits)-1]>>(bs.size%64) != 0 {
+		return fmt.Errorf("bits set past size %d", bs.size)
+	}
+	return nil
+}
+
+// wordsFor returns the number of uint64 words needed to hold size bits.
+func wordsFor(size int) int {
+	return (size + 63) / 64
+}
+
+// min returns the smaller of a and b.
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -1,6 +1,9 @@
 package bitset
 
 import (
+	"bytes"
+	"math/rand"
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -99,3 +102,436 @@ func TestOutOfRange(t *testing.T) {
 	_, err = bs.Test(1000)
 	assert.Error(t, err)
 }
+
+// randomBitSet returns a BitSet of the given size with random bits set, along with
+// a map-based reference of the positions that were set.
+func randomBitSet(t *testing.T, rng *rand.Rand, size int) (*BitSet, map[int]bool) {
+	t.Helper()
+	bs := NewBitSet(size)
+	reference := make(map[int]bool)
+	for i := 0; i < size/4; i++ {
+		pos := rng.Intn(size)
+		require.NoError(t, bs.Set(pos))
+		reference[pos] = true
+	}
+	return bs, reference
+}
+
+// assertMatchesReference checks that bs has exactly the bits in reference set.
+func assertMatchesReference(t *testing.T, bs *BitSet, reference map[int]bool, size int) {
+	t.Helper()
+	for pos := 0; pos < size; pos++ {
+		got, err := bs.Test(pos)
+		require.NoError(t, err)
+		assert.Equal(t, reference[pos], got, "position %d", pos)
+	}
+	assert.Equal(t, len(reference), bs.Count())
+}
+
+func TestSetOperations(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	const size = 1000
+
+	for i := 0; i < 10; i++ {
+		a, refA := randomBitSet(t, rng, size)
+		b, refB := randomBitSet(t, rng, size)
+
+		union, err := a.Union(b)
+		require.NoError(t, err)
+		refUnion := make(map[int]bool)
+		for pos := range refA {
+			refUnion[pos] = true
+		}
+		for pos := range refB {
+			refUnion[pos] = true
+		}
+		assertMatchesReference(t, union, refUnion, size)
+
+		intersection, err := a.Intersect(b)
+		require.NoError(t, err)
+		refIntersection := make(map[int]bool)
+		for pos := range refA {
+			if refB[pos] {
+				refIntersection[pos] = true
+			}
+		}
+		assertMatchesReference(t, intersection, refIntersection, size)
+
+		difference, err := a.Difference(b)
+		require.NoError(t, err)
+		refDifference := make(map[int]bool)
+		for pos := range refA {
+			if !refB[pos] {
+				refDifference[pos] = true
+			}
+		}
+		assertMatchesReference(t, difference, refDifference, size)
+
+		// The operands are left unchanged.
+		assertMatchesReference(t, a, refA, size)
+		assertMatchesReference(t, b, refB, size)
+	}
+}
+
+func TestSetOperationsSizeMismatch(t *testing.T) {
+	// 100 and 120 bits use the same number of words but are still different sizes.
+	for _, size := range []int{120, 128} {
+		a := NewBitSet(100)
+		b := NewBitSet(size)
+
+		_, err := a.Union(b)
+		assert.Error(t, err)
+
+		_, err = a.Intersect(b)
+		assert.Error(t, err)
+
+		_, err = a.Difference(b)
+		assert.Error(t, err)
+
+		assert.False(t, a.Equal(b))
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := NewBitSet(100)
+	b := NewBitSet(100)
+	assert.True(t, a.Equal(b))
+
+	require.NoError(t, a.Set(42))
+	assert.False(t, a.Equal(b))
+
+	require.NoError(t, b.Set(42))
+	assert.True(t, a.Equal(b))
+}
+
+func TestNextSet(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	const size = 1000
+	bs, reference := randomBitSet(t, rng, size)
+
+	for from := 0; from < size+10; from++ {
+		expected, found := -1, false
+		for pos := from; pos < size; pos++ {
+			if reference[pos] {
+				expected, found = pos, true
+				break
+			}
+		}
+		got, ok := bs.NextSet(from)
+		assert.Equal(t, found, ok, "from %d", from)
+		if found {
+			assert.Equal(t, expected, got, "from %d", from)
+		}
+	}
+}
+
+func TestNextSetEdgeCases(t *testing.T) {
+	bs := NewBitSet(128)
+
+	_, ok := bs.NextSet(0)
+	assert.False(t, ok)
+
+	require.NoError(t, bs.Set(0))
+	require.NoError(t, bs.Set(63))
+	require.NoError(t, bs.Set(64))
+	require.NoError(t, bs.Set(127))
+
+	testCases := []struct {
+		from     int
+		expected int
+	}{
+		{-5, 0},
+		{0, 0},
+		{1, 63},
+		{63, 63},
+		{64, 64},
+		{65, 127},
+		{127, 127},
+	}
+
+	for _, tc := range testCases {
+		got, ok := bs.NextSet(tc.from)
+		require.True(t, ok)
+		assert.Equal(t, tc.expected, got)
+	}
+
+	_, ok = bs.NextSet(128)
+	assert.False(t, ok)
+}
+
+func TestForEach(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	bs, reference := randomBitSet(t, rng, 1000)
+
+	expected := make([]int, 0, len(reference))
+	for pos := range reference {
+		expected = append(expected, pos)
+	}
+	sort.Ints(expected)
+
+	var got []int
+	bs.ForEach(func(pos int) bool {
+		got = append(got, pos)
+		return true
+	})
+	assert.Equal(t, expected, got)
+}
+
+func TestForEachStopsEarly(t *testing.T) {
+	bs := NewBitSet(100)
+	for _, pos := range []int{1, 5, 70, 99} {
+		require.NoError(t, bs.Set(pos))
+	}
+
+	var got []int
+	bs.ForEach(func(pos int) bool {
+		got = append(got, pos)
+		return len(got) < 2
+	})
+	assert.Equal(t, []int{1, 5}, got)
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+
+	for _, size := range []int{0, 1, 63, 64, 100, 10000} {
+		bs := NewBitSet(size)
+		reference := make(map[int]bool)
+		for i := 0; i < size/4; i++ {
+			pos := rng.Intn(size)
+			require.NoError(t, bs.Set(pos))
+			reference[pos] = true
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, bs.Serialize(&buf))
+		assert.Equal(t, 8+(size+63)/64*8, buf.Len())
+
+		restored := NewBitSet(1)
+		require.NoError(t, restored.Deserialize(&buf))
+		assert.Equal(t, size, restored.Size())
+		assert.True(t, bs.Equal(restored), "size %d", size)
+		assertMatchesReference(t, restored, reference, size)
+	}
+}
+
+func TestDeserializeTruncated(t *testing.T) {
+	bs := NewBitSet(200)
+	require.NoError(t, bs.Set(150))
+
+	var buf bytes.Buffer
+	require.NoError(t, bs.Serialize(&buf))
+	data := buf.Bytes()
+
+	for _, length := range []int{0, 4, 8, 20, len(data) - 1} {
+		restored := NewBitSet(10)
+		require.NoError(t, restored.Set(5))
+		assert.Error(t, restored.Deserialize(bytes.NewReader(data[:length])), "length %d", length)
+
+		// The BitSet is left unchanged.
+		assert.Equal(t, 10, restored.Size())
+		got, err := restored.Test(5)
+		require.NoError(t, err)
+		assert.True(t, got)
+	}
+}
+
+func TestDeserializeHugeSize(t *testing.T) {
+	// A corrupt size must not allocate memory for data that is not there.
+	data := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x0f, 1, 2, 3}
+	assert.Error(t, NewBitSet(1).Deserialize(bytes.NewReader(data)))
+}
+
+func TestGrow(t *testing.T) {
+	bs := NewBitSet(100)
+	for _, pos := range []int{0, 42, 99} {
+		require.NoError(t, bs.Set(pos))
+	}
+	countBefore := bs.Count()
+
+	require.NoError(t, bs.Grow(1000))
+	assert.Equal(t, 1000, bs.Size())
+	assert.Equal(t, countBefore, bs.Count())
+
+	for pos := 0; pos < 1000; pos++ {
+		got, err := bs.Test(pos)
+		require.NoError(t, err)
+		assert.Equal(t, pos == 0 || pos == 42 || pos == 99, got, "position %d", pos)
+	}
+
+	require.NoError(t, bs.Set(999))
+	assert.Equal(t, countBefore+1, bs.Count())
+}
+
+func TestGrowWithinWord(t *testing.T) {
+	bs := NewBitSet(10)
+	require.NoError(t, bs.Set(3))
+
+	require.NoError(t, bs.Grow(10))
+	require.NoError(t, bs.Grow(60))
+	assert.Equal(t, 60, bs.Size())
+	assert.Equal(t, 1, bs.Count())
+}
+
+func TestGrowShrinkRejected(t *testing.T) {
+	bs := NewBitSet(100)
+	require.NoError(t, bs.Set(99))
+
+	assert.Error(t, bs.Grow(50))
+	assert.Equal(t, 100, bs.Size())
+	got, err := bs.Test(99)
+	require.NoError(t, err)
+	assert.True(t, got)
+}
+
+func TestBytesAndFromBytes(t *testing.T) {
+	bs := NewBitSet(130)
+	for _, pos := range []int{1, 64, 129} {
+		require.NoError(t, bs.Set(pos))
+	}
+
+	data := bs.Bytes()
+	assert.Len(t, data, 24)
+
+	shared, err := FromBytes(data, 130)
+	require.NoError(t, err)
+	assert.True(t, bs.Equal(shared))
+
+	// The two bitsets share memory.
+	require.NoError(t, shared.Set(100))
+	got, err := bs.Test(100)
+	require.NoError(t, err)
+	assert.True(t, got)
+}
+
+func TestFromBytesUnaligned(t *testing.T) {
+	bs := NewBitSet(128)
+	require.NoError(t, bs.Set(70))
+
+	data := make([]byte, 17)
+	copy(data[1:], bs.Bytes())
+
+	copied, err := FromBytes(data[1:], 128)
+	require.NoError(t, err)
+	assert.True(t, bs.Equal(copied))
+}
+
+func TestFromBytesInvalid(t *testing.T) {
+	_, err := FromBytes(make([]byte, 8), 65)
+	assert.Error(t, err)
+
+	_, err = FromBytes(nil, -1)
+	assert.Error(t, err)
+
+	bs, err := FromBytes(nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, bs.Count())
+}
+
+func TestPositionsPastSize(t *testing.T) {
+	bs := NewBitSet(100)
+
+	for _, pos := range []int{100, 120, 127, -1, -63, -64} {
+		assert.Error(t, bs.Set(pos), "position %d", pos)
+		assert.Error(t, bs.Clear(pos), "position %d", pos)
+		_, err := bs.Test(pos)
+		assert.Error(t, err, "position %d", pos)
+	}
+	assert.Equal(t, 0, bs.Count())
+
+	// Growing exposes only cleared bits.
+	require.NoError(t, bs.Grow(128))
+	got, err := bs.Test(120)
+	require.NoError(t, err)
+	assert.False(t, got)
+	_, ok := bs.NextSet(0)
+	assert.False(t, ok)
+}
+
+func TestNegativeSize(t *testing.T) {
+	bs := NewBitSet(-5)
+	assert.Equal(t, 0, bs.Size())
+
+	var buf bytes.Buffer
+	require.NoError(t, bs.Serialize(&buf))
+
+	restored := NewBitSet(10)
+	require.NoError(t, restored.Deserialize(&buf))
+	assert.Equal(t, 0, restored.Size())
+}
+
+func TestBitsPastSizeRejected(t *testing.T) {
+	full := NewBitSet(128)
+	require.NoError(t, full.Set(120))
+
+	_, err := FromBytes(full.Bytes(), 100)
+	assert.Error(t, err)
+
+	// Serialized form of a 100-bit BitSet whose second word has bit 56 (position 120) set.
+	var buf bytes.Buffer
+	require.NoError(t, NewBitSet(100).Serialize(&buf))
+	data := buf.Bytes()
+	data[8+8+7] = 0x01
+	restored := NewBitSet(10)
+	assert.Error(t, restored.Deserialize(bytes.NewReader(data)))
+	assert.Equal(t, 10, restored.Size())
+}
+
+func TestInPlaceOperations(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	const size = 1000
+
+	operations := []struct {
+		name     string
+		inPlace  func(bs, other *BitSet) error
+		expected func(bs, other *BitSet) (*BitSet, error)
+	}{
+		{"Or", (*BitSet).Or, (*BitSet).Union},
+		{"And", (*BitSet).And, (*BitSet).Intersect},
+		{"AndNot", (*BitSet).AndNot, (*BitSet).Difference},
+	}
+
+	for _, op := range operations {
+		for i := 0; i < 10; i++ {
+			a, _ := randomBitSet(t, rng, size)
+			b, refB := randomBitSet(t, rng, size)
+
+			expected, err := op.expected(a, b)
+			require.NoError(t, err)
+			require.NoError(t, op.inPlace(a, b), op.name)
+			assert.True(t, expected.Equal(a), op.name)
+
+			// The other operand is left unchanged.
+			assertMatchesReference(t, b, refB, size)
+		}
+	}
+}
+
+func TestInPlaceOperationsSizeMismatch(t *testing.T) {
+	a := NewBitSet(100)
+	require.NoError(t, a.Set(5))
+	b := NewBitSet(120)
+	require.NoError(t, b.Set(110))
+
+	assert.Error(t, a.Or(b))
+	assert.Error(t, a.And(b))
+	assert.Error(t, a.AndNot(b))
+
+	assert.Equal(t, 1, a.Count())
+	got, err := a.Test(5)
+	require.NoError(t, err)
+	assert.True(t, got)
+}
+
+func TestInPlaceOperationsDoNotAllocate(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	a, _ := randomBitSet(t, rng, 1000)
+	b, _ := randomBitSet(t, rng, 1000)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = a.Or(b)
+		_ = a.And(b)
+		_ = a.AndNot(b)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
@@ -78,45 +78,118 @@ Here are some example calculations of the false positive probability with varyin
     P(false positive) ≈ 0.028 (2.8%)
 
 These examples show how increasing the bitset size or the number of hash functions can reduce the probability of false positives.
+
+### Optimal Parameters
+
+Given the expected number of elements `n` and a target false positive rate `p`,
+`NewBloomFilterWithEstimates` picks the bitset size and the number of hash
+functions that minimize memory:
+
+	m = ceil(-n * ln(p) / ln(2)^2)
+	k = round(m / n * ln(2))
+
+Rather than requiring `k` independent hash functions, such a filter derives them
+by double hashing two base FNV hashes (`h1` is FNV-1a, `h2` is FNV-1):
+
+	index_i = (h1 + i * (h2 | 1)) mod m, for i in [0, k)
+
+### Serialization
+
+The bitset is stored as packed `uint64` words, one bit per position, so a
+filter of `m` bits uses `(m+63)/64` words instead of `m` booleans. A filter
+can be written with `Serialize` and restored with `Deserialize`. The encoded
+form contains an identifier of the hash function configuration, the bitset
+size, and the packed words, all in little-endian order. Hash functions cannot
+be serialized, so `Deserialize` must be called on a filter created with the
+same hash functions and fails if the identifiers do not match.
 */
 package bloomfilter
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+	"strings"
 )
 
+// maxHashConfigLength bounds the hash configuration identifier read by
+// Deserialize, so that corrupt input cannot trigger a huge allocation.
+const maxHashConfigLength = 1 << 16
+
+// readChunkWords is the number of words Deserialize reads at a time, so that memory
+// is only allocated for data that is actually present in the input.
+const readChunkWords = 1024
+
+// hashProbe is hashed by every hash function to fingerprint its configuration, so
+// that functions sharing a concrete type but configured differently (e.g. CRC-32
+// with different polynomials) are told apart.
+const hashProbe = "bloomfilter-hash-probe"
+
+// maxSize is the largest bitset size: 32-bit hash values cannot address more bits,
+// and on 32-bit platforms the size must also fit in an int.
+const maxSize = min(uint64(1)<<32, uint64(math.MaxInt))
+
+// hasher maps elements to positions in a filter of a given size. It is shared by
+// BloomFilter and CountingBloomFilter.
+type hasher struct {
+	size          int
+	hashFunctions []hash.Hash32
+	// hashCount is the number of indexes derived by double hashing from baseHashes.
+	// It is only used when the filter has no explicit hash functions.
+	hashCount  int
+	baseHashes [2]hash.Hash32
+}
+
 // BloomFilter represents a simple Bloom Filter data structure.
 type BloomFilter struct {
-	bitset        []bool
-	hashFunctions []hash.Hash32
+	hasher
+	bitset []uint64
 }
 
 // NewBloomFilter initializes a new Bloom Filter with the given size and hash functions.
 // It returns an error if the size is less than or equal to zero or if no hash functions are provided.
 func NewBloomFilter(m int, hashFunctions []hash.Hash32) (*BloomFilter, error) {
-	if m <= 0 {
-		return nil, errors.New("bloom filter size must be greater than zero")
+	h, err := newHasher(m, hashFunctions)
+	if err != nil {
+		return nil, err
 	}
-	if len(hashFunctions) == 0 {
-		return nil, errors.New("at least one hash function is required")
+
+	return &BloomFilter{
+		hasher: h,
+		bitset: make([]uint64, wordsFor(m)),
+	}, nil
+}
+
+// NewBloomFilterWithEstimates initializes a new Bloom Filter sized to hold expectedItems
+// elements with a false positive rate close to falsePositiveRate. The bitset size and
+// the number of hash functions are computed with the standard formulas, and the hash
+// functions are derived internally by double hashing.
+// It returns an error if expectedItems is not positive, if falsePositiveRate is not
+// strictly between 0 and 1, or if the resulting bitset would be too large.
+func NewBloomFilterWithEstimates(expectedItems int, falsePositiveRate float64) (*BloomFilter, error) {
+	h, err := newHasherWithEstimates(expectedItems, falsePositiveRate)
+	if err != nil {
+		return nil, err
 	}
 
 	return &BloomFilter{
-		bitset:        make([]bool, m),
-		hashFunctions: hashFunctions,
+		hasher: h,
+		bitset: make([]uint64, wordsFor(h.size)),
 	}, nil
 }
 
 // Add inserts an element into the Bloom Filter. It computes an index for each hash
-// function and sets the corresponding bit in the bitset to `true`.
+// function and sets the corresponding bit in the bitset.
 func (bf *BloomFilter) Add(element string) {
-	for _, hashFunction := range bf.hashFunctions {
-		hashFunction.Reset()
-		hashFunction.Write([]byte(element))
-		index := int(hashFunction.Sum32()) % len(bf.bitset)
-		bf.bitset[index] = true
-	}
+	bf.forEachIndex(element, func(index int) bool {
+		bf.bitset[index/64] |= 1 << (index % 64)
+		return true
+	})
 }
 
 // Contains checks if an element might be present in the Bloom Filter. It returns `true`
@@ -124,13 +197,268 @@ func (bf *BloomFilter) Add(element string) {
 // If any bit is not set, the element is definitely not in the set. However, even if
 // all bits are set, there is still a possibility of a false positive.
 func (bf *BloomFilter) Contains(element string) bool {
-	for _, hashFunction := range bf.hashFunctions {
-		hashFunction.Reset()
-		hashFunction.Write([]byte(element))
-		index := int(hashFunction.Sum32()) % len(bf.bitset)
-		if !bf.bitset[index] {
+	contains := true
+	bf.forEachIndex(element, func(index int) bool {
+		contains = bf.bitset[index/64]&(1<<(index%64)) != 0
+		return contains
+	})
+	return contains
+}
+
+// EstimatedFalsePositiveRate returns the expected false positive rate of the filter
+// after insertedItems elements have been added, using (1 - e^(-kn/m))^k.
+func (bf *BloomFilter) EstimatedFalsePositiveRate(insertedItems int) float64 {
+	k := float64(bf.numHashes())
+	n := float64(insertedItems)
+	m := float64(bf.size)
+	return math.Pow(1-math.Exp(-k*n/m), k)
+}
+
+// ApproximateCount estimates the number of distinct elements added to the filter
+// from the fraction of set bits X/m, using -(m/k) * ln(1 - X/m).
+// When every bit is set the estimate is unbounded and math.MaxInt is returned.
+func (bf *BloomFilter) ApproximateCount() int {
+	setBits := 0
+	for _, word := range bf.bitset {
+		setBits += bits.OnesCount64(word)
+	}
+	if setBits >= bf.size {
+		return math.MaxInt
+	}
+	k := float64(bf.numHashes())
+	m := float64(bf.size)
+	return int(math.Round(-(m / k) * math.Log(1-float64(setBits)/m)))
+}
+
+// Union returns a new Bloom Filter containing every element added to either filter.
+// It returns an error if the two filters have different sizes or hash configurations.
+func (bf *BloomFilter) Union(other *BloomFilter) (*BloomFilter, error) {
+	if err := bf.checkCompatible(&other.hasher); err != nil {
+		return nil, err
+	}
+	result := bf.Copy()
+	for i, word := range other.bitset {
+		result.bitset[i] |= word
+	}
+	return result, nil
+}
+
+// Intersect returns a new Bloom Filter whose bits are set only where they are set in
+// both filters. Elements added to both filters are always reported as present, while
+// the false positive rate may be higher than that of a filter built from the common
+// elements alone.
+// It returns an error if the two filters have different sizes or hash configurations.
+func (bf *BloomFilter) Intersect(other *BloomFilter) (*BloomFilter, error) {
+	if err := bf.checkCompatible(&other.hasher); err != nil {
+		return nil, err
+	}
+	result := bf.Copy()
+	for i, word := range other.bitset {
+		result.bitset[i] &= word
+	}
+	return result, nil
+}
+
+// Equals returns true if both filters have the same size, the same hash
+// configuration and the same bits set.
+func (bf *BloomFilter) Equals(other *BloomFilter) bool {
+	if bf.checkCompatible(&other.hasher) != nil {
+		return false
+	}
+	for i, word := range bf.bitset {
+		if word != other.bitset[i] {
 			return false
 		}
 	}
 	return true
 }
+
+// Copy returns a deep copy of the bitset. User-supplied hash functions are shared
+// with the copy, so the two filters must not be used concurrently.
+func (bf *BloomFilter) Copy() *BloomFilter {
+	result := &BloomFilter{
+		hasher: bf.hasher.copy(),
+		bitset: make([]uint64, len(bf.bitset)),
+	}
+	copy(result.bitset, bf.bitset)
+	return result
+}
+
+// Serialize writes the Bloom Filter to w. The output holds the hash configuration
+// identifier, the bitset size, and the bitset as packed uint64 words.
+func (bf *BloomFilter) Serialize(w io.Writer) error {
+	config := bf.hashConfig()
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(config))); err != nil {
+		return fmt.Errorf("failed to write hash configuration length: %w", err)
+	}
+	if _, err := io.WriteString(w, config); err != nil {
+		return fmt.Errorf("failed to write hash configuration: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(bf.size)); err != nil {
+		return fmt.Errorf("failed to write bitset size: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, bf.bitset); err != nil {
+		return fmt.Errorf("failed to write bitset: %w", err)
+	}
+	return nil
+}
+
+// Deserialize replaces the contents of the Bloom Filter with the data read from r,
+// as written by Serialize. The filter must have been created with the same hash
+// functions used by the serialized filter; otherwise an error is returned and the
+// filter is left unchanged.
+func (bf *BloomFilter) Deserialize(r io.Reader) error {
+	var configLength uint32
+	if err := binary.Read(r, binary.LittleEndian, &configLength); err != nil {
+		return fmt.Errorf("failed to read hash configuration length: %w", err)
+	}
+	if configLength > maxHashConfigLength {
+		return fmt.Errorf("invalid hash configuration length: %d", configLength)
+	}
+	config := make([]byte, configLength)
+	if _, err := io.ReadFull(r, config); err != nil {
+		return fmt.Errorf("failed to read hash configuration: %w", err)
+	}
+	if expected := bf.hashConfig(); string(config) != expected {
+		return fmt.Errorf("hash configuration mismatch: got %q, expected %q", config, expected)
+	}
+
+	var size uint64
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return fmt.Errorf("failed to read bitset size: %w", err)
+	}
+	if size == 0 || size > maxSize {
+		return fmt.Errorf("invalid bitset size: %d", size)
+	}
+	words := wordsFor(int(size))
+	bitset := make([]uint64, 0, min(words, readChunkWords))
+	for len(bitset) < words {
+		chunk := make([]uint64, min(words-len(bitset), readChunkWords))
+		if err := binary.Read(r, binary.LittleEndian, chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("failed to read bitset: %w", err)
+		}
+		bitset = append(bitset, chunk...)
+	}
+	if unused := size % 64; unused != 0 && bitset[words-1]>>unused != 0 {
+		return fmt.Errorf("bits set past bitset size %d", size)
+	}
+
+	bf.size = int(size)
+	bf.bitset = bitset
+	return nil
+}
+
+// newHasher returns a hasher using the given size and hash functions.
+func newHasher(m int, hashFunctions []hash.Hash32) (hasher, error) {
+	if m <= 0 {
+		return hasher{}, errors.New("bloom filter size must be greater than zero")
+	}
+	if len(hashFunctions) == 0 {
+		return hasher{}, errors.New("at least one hash function is required")
+	}
+	return hasher{size: m, hashFunctions: hashFunctions}, nil
+}
+
+// newHasherWithEstimates returns a double hashing hasher with the optimal size and
+// number of hashes for the expected number of items and false positive rate.
+func newHasherWithEstimates(expectedItems int, falsePositiveRate float64) (hasher, error) {
+	if expectedItems <= 0 {
+		return hasher{}, errors.New("expected items must be greater than zero")
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return hasher{}, errors.New("false positive rate must be between 0 and 1")
+	}
+
+	n := float64(expectedItems)
+	m := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	if m > float64(maxSize) {
+		return hasher{}, fmt.Errorf("bloom filter size %.0f exceeds the maximum of %d bits", m, maxSize)
+	}
+	k := int(math.Max(1, math.Round(m/n*math.Ln2)))
+
+	return hasher{
+		size:       int(m),
+		hashCount:  k,
+		baseHashes: [2]hash.Hash32{fnv.New32a(), fnv.New32()},
+	}, nil
+}
+
+// copy returns a hasher with the same configuration. Base hashes used for double
+// hashing are recreated, while user-supplied hash functions are shared.
+func (h hasher) copy() hasher {
+	if len(h.hashFunctions) == 0 {
+		h.baseHashes = [2]hash.Hash32{fnv.New32a(), fnv.New32()}
+	}
+	return h
+}
+
+// checkCompatible returns an error if other has a different size or hash configuration.
+func (h *hasher) checkCompatible(other *hasher) error {
+	if h.size != other.size {
+		return fmt.Errorf("bloom filter size mismatch: %d and %d", h.size, other.size)
+	}
+	if config, otherConfig := h.hashConfig(), other.hashConfig(); config != otherConfig {
+		return fmt.Errorf("hash configuration mismatch: %q and %q", config, otherConfig)
+	}
+	return nil
+}
+
+// forEachIndex calls fn with each bit position of element, stopping early if fn returns false.
+func (h *hasher) forEachIndex(element string, fn func(index int) bool) {
+	if len(h.hashFunctions) == 0 {
+		h1 := uint64(sum32(h.baseHashes[0], element))
+		// FNV-1 and FNV-1a always agree on their lowest bit, which makes the derived
+		// indexes collide far more often than expected. Forcing h2 to be odd breaks
+		// the correlation.
+		h2 := uint64(sum32(h.baseHashes[1], element)) | 1
+		for i := 0; i < h.hashCount; i++ {
+			if !fn(int((h1 + uint64(i)*h2) % uint64(h.size))) {
+				return
+			}
+		}
+		return
+	}
+	for _, hashFunction := range h.hashFunctions {
+		// Reduce in uint64 so that the index is never negative on 32-bit platforms.
+		if !fn(int(uint64(sum32(hashFunction, element)) % uint64(h.size))) {
+			return
+		}
+	}
+}
+
+// numHashes returns the number of bit positions computed for each element.
+func (h *hasher) numHashes() int {
+	if len(h.hashFunctions) == 0 {
+		return h.hashCount
+	}
+	return len(h.hashFunctions)
+}
+
+// hashConfig returns an identifier of the hash functions, built in order from
+// their concrete types and their hash of hashProbe (e.g. "*fnv.sum32:4c1a2b3d"),
+// or from the number of derived hashes when double hashing is used.
+func (h *hasher) hashConfig() string {
+	if len(h.hashFunctions) == 0 {
+		return fmt.Sprintf("fnv-double-hashing:%d", h.hashCount)
+	}
+	names := make([]string, len(h.hashFunctions))
+	for i, hashFunction := range h.hashFunctions {
+		names[i] = fmt.Sprintf("%T:%08x", hashFunction, sum32(hashFunction, hashProbe))
+	}
+	return strings.Join(names, ",")
+}
+
+// sum32 returns the 32-bit hash of element computed by hashFunction.
+func sum32(hashFunction hash.Hash32, element string) uint32 {
+	hashFunction.Reset()
+	hashFunction.Write([]byte(element))
+	return hashFunction.Sum32()
+}
+
+// wordsFor returns the number of uint64 words needed to hold m bits.
+func wordsFor(m int) int {
+	return (m + 63) / 64
+}
@@ -2,9 +2,14 @@ package bloomfilter_test
 
 import (
 	"bloomfilter"
+	"bytes"
+	"encoding/binary"
 	"hash"
+	"hash/crc32"
 	"hash/fnv"
+	"math"
 	"math/rand"
+	"runtime"
 	"testing"
 	"time"
 
@@ -116,6 +121,355 @@ func TestBloomFilter_VeryRareData(t *testing.T) {
 	assert.False(t, bf.Contains("this_is_a_very_long_string_that_is_unlikely_to_collide"))
 }
 
+func TestBloomFilter_SerializeRoundTrip(t *testing.T) {
+	hashFunctions := []hash.Hash32{fnv.New32(), fnv.New32a()}
+	bf, err := bloomfilter.NewBloomFilter(1000, hashFunctions)
+	require.NoError(t, err)
+
+	added := generateRandomStrings(100, 10)
+	for _, str := range added {
+		bf.Add(str)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, bf.Serialize(&buf))
+
+	restored, err := bloomfilter.NewBloomFilter(1, []hash.Hash32{fnv.New32(), fnv.New32a()})
+	require.NoError(t, err)
+	require.NoError(t, restored.Deserialize(&buf))
+
+	for _, str := range added {
+		assert.True(t, restored.Contains(str), "Expected '%s' to be in the restored Bloom Filter", str)
+	}
+	for _, str := range generateRandomStrings(1000, 12) {
+		assert.Equal(t, bf.Contains(str), restored.Contains(str), "Mismatch for '%s'", str)
+	}
+}
+
+func TestBloomFilter_SerializeSize(t *testing.T) {
+	hashFunctions := []hash.Hash32{fnv.New32()}
+	bf, err := bloomfilter.NewBloomFilter(1000, hashFunctions)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, bf.Serialize(&buf))
+
+	// 4 bytes of config length, the config, 8 bytes of size and 16 words of 8 bytes.
+	require.GreaterOrEqual(t, buf.Len(), 4)
+	configLength := int(binary.LittleEndian.Uint32(buf.Bytes()))
+	assert.Positive(t, configLength)
+	assert.Equal(t, 4+configLength+8+16*8, buf.Len())
+}
+
+func TestBloomFilter_DeserializeHashMismatch(t *testing.T) {
+	bf, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{fnv.New32(), fnv.New32a()})
+	require.NoError(t, err)
+	bf.Add("apple")
+
+	var buf bytes.Buffer
+	require.NoError(t, bf.Serialize(&buf))
+
+	other, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{fnv.New32a(), fnv.New32()})
+	require.NoError(t, err)
+	err = other.Deserialize(&buf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hash configuration mismatch")
+	assert.False(t, other.Contains("apple"))
+}
+
+func TestBloomFilter_DeserializeSameTypeHashMismatch(t *testing.T) {
+	// Both hash functions share the same concrete type, *crc32.digest.
+	bf, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{crc32.NewIEEE()})
+	require.NoError(t, err)
+	bf.Add("apple")
+
+	var buf bytes.Buffer
+	require.NoError(t, bf.Serialize(&buf))
+
+	other, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{crc32.New(crc32.MakeTable(crc32.Castagnoli))})
+	require.NoError(t, err)
+	err = other.Deserialize(&buf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hash configuration mismatch")
+}
+
+func TestBloomFilter_DeserializeHugeSize(t *testing.T) {
+	bf, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{fnv.New32()})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, bf.Serialize(&buf))
+	header := buf.Bytes()[:buf.Len()-8-16*8]
+
+	// A corrupt size must not allocate memory for data that is not there.
+	corrupt := append([]byte{}, header...)
+	corrupt = append(corrupt, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 1, 2, 3)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err = bf.Deserialize(bytes.NewReader(corrupt))
+	runtime.ReadMemStats(&after)
+
+	require.Error(t, err)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+}
+
+func TestBloomFilter_DeserializeBitsPastSize(t *testing.T) {
+	bf, err := bloomfilter.NewBloomFilter(10, []hash.Hash32{fnv.New32()})
+	require.NoError(t, err)
+	bf.Add("apple")
+
+	var buf bytes.Buffer
+	require.NoError(t, bf.Serialize(&buf))
+	data := buf.Bytes()
+	// Set every bit of the only word, including the 54 bits past the size.
+	copy(data[len(data)-8:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	restored, err := bloomfilter.NewBloomFilter(10, []hash.Hash32{fnv.New32()})
+	require.NoError(t, err)
+	err = restored.Deserialize(bytes.NewReader(data))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bits set past bitset size")
+	assert.False(t, restored.Contains("apple"))
+	assert.Equal(t, 0, restored.ApproximateCount())
+}
+
+func TestBloomFilter_DeserializeTruncated(t *testing.T) {
+	hashFunctions := []hash.Hash32{fnv.New32(), fnv.New32a()}
+	bf, err := bloomfilter.NewBloomFilter(1000, hashFunctions)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, bf.Serialize(&buf))
+	data := buf.Bytes()
+
+	for _, length := range []int{0, 2, 10, len(data) - 1} {
+		other, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{fnv.New32(), fnv.New32a()})
+		require.NoError(t, err)
+		assert.Error(t, other.Deserialize(bytes.NewReader(data[:length])), "length %d", length)
+	}
+}
+
+func TestNewBloomFilterWithEstimates_InvalidInputs(t *testing.T) {
+	testCases := []struct {
+		expectedItems     int
+		falsePositiveRate float64
+		expectedError     string
+	}{
+		{0, 0.01, "expected items must be greater than zero"},
+		{-1, 0.01, "expected items must be greater than zero"},
+		{1000, 0, "false positive rate must be between 0 and 1"},
+		{1000, 1, "false positive rate must be between 0 and 1"},
+		{1000, -0.5, "false positive rate must be between 0 and 1"},
+	}
+
+	for _, tc := range testCases {
+		bf, err := bloomfilter.NewBloomFilterWithEstimates(tc.expectedItems, tc.falsePositiveRate)
+		require.Error(t, err)
+		assert.EqualError(t, err, tc.expectedError)
+		assert.Nil(t, bf)
+	}
+}
+
+func TestBloomFilterWithEstimates_AddAndContains(t *testing.T) {
+	bf, err := bloomfilter.NewBloomFilterWithEstimates(1000, 0.01)
+	require.NoError(t, err)
+
+	randomData := generateRandomStrings(1000, 10)
+	for _, str := range randomData {
+		bf.Add(str)
+	}
+
+	for _, str := range randomData {
+		assert.True(t, bf.Contains(str), "Expected '%s' to be in the Bloom Filter", str)
+	}
+}
+
+func TestBloomFilterWithEstimates_FalsePositiveRate(t *testing.T) {
+	for _, falsePositiveRate := range []float64{0.1, 0.01, 0.001} {
+		bf, err := bloomfilter.NewBloomFilterWithEstimates(10000, falsePositiveRate)
+		require.NoError(t, err)
+
+		for _, str := range generateRandomStrings(10000, 10) {
+			bf.Add(str)
+		}
+
+		// Probes are longer than the inserted strings, so every hit is a false positive.
+		falsePositives := 0
+		probes := generateRandomStrings(100000, 12)
+		for _, str := range probes {
+			if bf.Contains(str) {
+				falsePositives++
+			}
+		}
+
+		measured := float64(falsePositives) / float64(len(probes))
+		assert.LessOrEqual(t, measured, 2*falsePositiveRate, "requested rate %v", falsePositiveRate)
+		assert.InDelta(t, falsePositiveRate, bf.EstimatedFalsePositiveRate(10000), falsePositiveRate/2)
+	}
+}
+
+func TestBloomFilterWithEstimates_SerializeRoundTrip(t *testing.T) {
+	bf, err := bloomfilter.NewBloomFilterWithEstimates(500, 0.01)
+	require.NoError(t, err)
+
+	added := generateRandomStrings(500, 10)
+	for _, str := range added {
+		bf.Add(str)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, bf.Serialize(&buf))
+
+	restored, err := bloomfilter.NewBloomFilterWithEstimates(500, 0.01)
+	require.NoError(t, err)
+	require.NoError(t, restored.Deserialize(bytes.NewReader(buf.Bytes())))
+	for _, str := range added {
+		assert.True(t, restored.Contains(str), "Expected '%s' to be in the restored Bloom Filter", str)
+	}
+
+	// A different false positive rate yields a different number of derived hashes.
+	other, err := bloomfilter.NewBloomFilterWithEstimates(500, 0.1)
+	require.NoError(t, err)
+	assert.Error(t, other.Deserialize(bytes.NewReader(buf.Bytes())))
+}
+
+func TestBloomFilter_EstimatedFalsePositiveRate(t *testing.T) {
+	hashFunctions := []hash.Hash32{fnv.New32(), fnv.New32a(), fnv.New32()}
+	bf, err := bloomfilter.NewBloomFilter(1000, hashFunctions)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0.0, bf.EstimatedFalsePositiveRate(0))
+	assert.InDelta(t, 0.0174, bf.EstimatedFalsePositiveRate(100), 0.0001)
+	assert.Less(t, bf.EstimatedFalsePositiveRate(100), bf.EstimatedFalsePositiveRate(200))
+}
+
+func TestBloomFilter_ApproximateCount(t *testing.T) {
+	bf, err := bloomfilter.NewBloomFilterWithEstimates(5000, 0.01)
+	require.NoError(t, err)
+	assert.Equal(t, 0, bf.ApproximateCount())
+
+	for _, str := range generateRandomStrings(2000, 10) {
+		bf.Add(str)
+	}
+	assert.InDelta(t, 2000, bf.ApproximateCount(), 100)
+}
+
+func TestBloomFilter_ApproximateCountSaturated(t *testing.T) {
+	hashFunctions := []hash.Hash32{fnv.New32a()}
+	bf, err := bloomfilter.NewBloomFilter(1, hashFunctions)
+	require.NoError(t, err)
+
+	bf.Add("apple")
+	assert.Equal(t, math.MaxInt, bf.ApproximateCount())
+}
+
+func TestBloomFilter_Union(t *testing.T) {
+	a, err := bloomfilter.NewBloomFilterWithEstimates(2000, 0.01)
+	require.NoError(t, err)
+	b, err := bloomfilter.NewBloomFilterWithEstimates(2000, 0.01)
+	require.NoError(t, err)
+
+	dataA := generateRandomStrings(1000, 10)
+	dataB := generateRandomStrings(1000, 10)
+	for _, str := range dataA {
+		a.Add(str)
+	}
+	for _, str := range dataB {
+		b.Add(str)
+	}
+
+	union, err := a.Union(b)
+	require.NoError(t, err)
+	for _, str := range append(dataA, dataB...) {
+		assert.True(t, union.Contains(str), "Expected '%s' to be in the union", str)
+	}
+
+	// The operands are left unchanged.
+	assert.False(t, a.Equals(union))
+	assert.False(t, b.Equals(union))
+}
+
+func TestBloomFilter_Intersect(t *testing.T) {
+	hashFunctions := []hash.Hash32{fnv.New32(), fnv.New32a()}
+	a, err := bloomfilter.NewBloomFilter(10000, hashFunctions)
+	require.NoError(t, err)
+	b, err := bloomfilter.NewBloomFilter(10000, hashFunctions)
+	require.NoError(t, err)
+
+	common := generateRandomStrings(500, 10)
+	for _, str := range common {
+		a.Add(str)
+		b.Add(str)
+	}
+	for _, str := range generateRandomStrings(500, 10) {
+		a.Add(str)
+	}
+	for _, str := range generateRandomStrings(500, 10) {
+		b.Add(str)
+	}
+
+	intersection, err := a.Intersect(b)
+	require.NoError(t, err)
+	for _, str := range common {
+		assert.True(t, intersection.Contains(str), "Expected '%s' to be in the intersection", str)
+	}
+}
+
+func TestBloomFilter_UnionAndIntersectMismatch(t *testing.T) {
+	a, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{fnv.New32(), fnv.New32a()})
+	require.NoError(t, err)
+	differentSize, err := bloomfilter.NewBloomFilter(2000, []hash.Hash32{fnv.New32(), fnv.New32a()})
+	require.NoError(t, err)
+	differentHashes, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{fnv.New32a()})
+	require.NoError(t, err)
+
+	for _, other := range []*bloomfilter.BloomFilter{differentSize, differentHashes} {
+		union, err := a.Union(other)
+		assert.Error(t, err)
+		assert.Nil(t, union)
+
+		intersection, err := a.Intersect(other)
+		assert.Error(t, err)
+		assert.Nil(t, intersection)
+
+		assert.False(t, a.Equals(other))
+	}
+}
+
+func TestBloomFilter_UnionAndIntersectSameTypeHashMismatch(t *testing.T) {
+	// Both hash functions share the same concrete type, *crc32.digest.
+	ieee, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{crc32.NewIEEE()})
+	require.NoError(t, err)
+	castagnoli, err := bloomfilter.NewBloomFilter(1000, []hash.Hash32{crc32.New(crc32.MakeTable(crc32.Castagnoli))})
+	require.NoError(t, err)
+
+	union, err := ieee.Union(castagnoli)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "hash configuration mismatch")
+	assert.Nil(t, union)
+
+	intersection, err := ieee.Intersect(castagnoli)
+	require.Error(t, err)
+	assert.Nil(t, intersection)
+
+	assert.False(t, ieee.Equals(castagnoli))
+}
+
+func TestBloomFilter_CopyAndEquals(t *testing.T) {
+	bf, err := bloomfilter.NewBloomFilterWithEstimates(100, 0.01)
+	require.NoError(t, err)
+	bf.Add("apple")
+
+	copied := bf.Copy()
+	assert.True(t, bf.Equals(copied))
+	assert.True(t, copied.Contains("apple"))
+
+	copied.Add("banana")
+	assert.False(t, bf.Equals(copied))
+	assert.False(t, bf.Contains("banana"))
+}
+
 func generateRandomStrings(count, length int) []string {
 	charset := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	result := make([]string, count)
@@ -0,0 +1,101 @@
+package bloomfilter
+
+import "hash"
+
+// maxCount is the value at which a counter saturates. A saturated counter is never
+// decremented again, because the number of elements it accounts for is unknown.
+const maxCount = ^uint8(0)
+
+// CountingBloomFilter is a Bloom Filter variant that keeps an 8-bit counter per
+// position instead of a single bit, which allows elements to be removed.
+type CountingBloomFilter struct {
+	hasher
+	counters []uint8
+}
+
+// NewCountingBloomFilter initializes a new Counting Bloom Filter with the given size and hash functions.
+// It returns an error if the size is less than or equal to zero or if no hash functions are provided.
+func NewCountingBloomFilter(m int, hashFunctions []hash.Hash32) (*CountingBloomFilter, error) {
+	h, err := newHasher(m, hashFunctions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CountingBloomFilter{
+		hasher:   h,
+		counters: make([]uint8, m),
+	}, nil
+}
+
+// NewCountingBloomFilterWithEstimates initializes a new Counting Bloom Filter sized like
+// the filter returned by NewBloomFilterWithEstimates for the same arguments.
+func NewCountingBloomFilterWithEstimates(expectedItems int, falsePositiveRate float64) (*CountingBloomFilter, error) {
+	h, err := newHasherWithEstimates(expectedItems, falsePositiveRate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CountingBloomFilter{
+		hasher:   h,
+		counters: make([]uint8, h.size),
+	}, nil
+}
+
+// Add inserts an element into the filter by incrementing the counter of each of its
+// positions. Counters saturate at their maximum value instead of overflowing.
+func (cbf *CountingBloomFilter) Add(element string) {
+	cbf.forEachIndex(element, func(index int) bool {
+		if cbf.counters[index] < maxCount {
+			cbf.counters[index]++
+		}
+		return true
+	})
+}
+
+// Remove deletes an element from the filter by decrementing the counter of each of its
+// positions. It returns false, leaving the filter unchanged, if any of the counters is
+// zero, since the element is then definitely not in the filter. Saturated counters are
+// left untouched so that later removals cannot cause false negatives.
+// Removing an element that was never added, but is reported as a false positive,
+// corrupts the filter and may cause false negatives for other elements.
+func (cbf *CountingBloomFilter) Remove(element string) bool {
+	if !cbf.Contains(element) {
+		return false
+	}
+	cbf.forEachIndex(element, func(index int) bool {
+		if cbf.counters[index] < maxCount {
+			cbf.counters[index]--
+		}
+		return true
+	})
+	return true
+}
+
+// Contains checks if an element might be present in the filter. It returns `true` if
+// the counters of all its positions are greater than zero; as with BloomFilter, false
+// positives are possible but false negatives are not.
+func (cbf *CountingBloomFilter) Contains(element string) bool {
+	contains := true
+	cbf.forEachIndex(element, func(index int) bool {
+		contains = cbf.counters[index] > 0
+		return contains
+	})
+	return contains
+}
+
+// ToBloomFilter returns a plain Bloom Filter with the same size and hash configuration,
+// where a bit is set for each non-zero counter. The result can be serialized and
+// reports the same membership as the counting filter. User-supplied hash functions
+// are shared with the result, so the two filters must not be used concurrently.
+func (cbf *CountingBloomFilter) ToBloomFilter() *BloomFilter {
+	bf := &BloomFilter{
+		hasher: cbf.hasher.copy(),
+		bitset: make([]uint64, wordsFor(cbf.size)),
+	}
+	for index, count := range cbf.counters {
+		if count > 0 {
+			bf.bitset[index/64] |= 1 << (index % 64)
+		}
+	}
+	return bf
+}
@@ -0,0 +1,146 @@
+package bloomfilter_test
+
+import (
+	"bloomfilter"
+	"hash"
+	"hash/fnv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCountingBloomFilter_InvalidInputs(t *testing.T) {
+	cbf, err := bloomfilter.NewCountingBloomFilter(0, []hash.Hash32{fnv.New32()})
+	assert.EqualError(t, err, "bloom filter size must be greater than zero")
+	assert.Nil(t, cbf)
+
+	cbf, err = bloomfilter.NewCountingBloomFilter(1000, []hash.Hash32{})
+	assert.EqualError(t, err, "at least one hash function is required")
+	assert.Nil(t, cbf)
+
+	cbf, err = bloomfilter.NewCountingBloomFilterWithEstimates(1000, 1.5)
+	assert.EqualError(t, err, "false positive rate must be between 0 and 1")
+	assert.Nil(t, cbf)
+}
+
+func TestCountingBloomFilter_AddAndRemove(t *testing.T) {
+	hashFunctions := []hash.Hash32{fnv.New32(), fnv.New32a()}
+	cbf, err := bloomfilter.NewCountingBloomFilter(1000, hashFunctions)
+	require.NoError(t, err)
+
+	cbf.Add("apple")
+	cbf.Add("banana")
+	assert.True(t, cbf.Contains("apple"))
+	assert.True(t, cbf.Contains("banana"))
+
+	assert.True(t, cbf.Remove("apple"))
+	assert.False(t, cbf.Contains("apple"))
+	assert.True(t, cbf.Contains("banana"))
+
+	assert.True(t, cbf.Remove("banana"))
+	assert.False(t, cbf.Contains("banana"))
+}
+
+func TestCountingBloomFilter_RemoveNotAdded(t *testing.T) {
+	hashFunctions := []hash.Hash32{fnv.New32(), fnv.New32a()}
+	cbf, err := bloomfilter.NewCountingBloomFilter(1000, hashFunctions)
+	require.NoError(t, err)
+
+	assert.False(t, cbf.Remove("apple"))
+
+	cbf.Add("banana")
+	assert.False(t, cbf.Remove("apple"))
+	assert.True(t, cbf.Contains("banana"))
+
+	// Removing twice only succeeds once.
+	assert.True(t, cbf.Remove("banana"))
+	assert.False(t, cbf.Remove("banana"))
+}
+
+func TestCountingBloomFilter_AddedTwice(t *testing.T) {
+	cbf, err := bloomfilter.NewCountingBloomFilterWithEstimates(100, 0.01)
+	require.NoError(t, err)
+
+	cbf.Add("apple")
+	cbf.Add("apple")
+
+	assert.True(t, cbf.Remove("apple"))
+	assert.True(t, cbf.Contains("apple"))
+	assert.True(t, cbf.Remove("apple"))
+	assert.False(t, cbf.Contains("apple"))
+}
+
+func TestCountingBloomFilter_Interleaved(t *testing.T) {
+	cbf, err := bloomfilter.NewCountingBloomFilterWithEstimates(2000, 0.01)
+	require.NoError(t, err)
+
+	kept := generateRandomStrings(1000, 10)
+	removed := generateRandomStrings(1000, 12)
+	for i := range kept {
+		cbf.Add(kept[i])
+		cbf.Add(removed[i])
+		if i%2 == 1 {
+			require.True(t, cbf.Remove(removed[i-1]))
+			require.True(t, cbf.Remove(removed[i]))
+		}
+	}
+
+	for _, str := range kept {
+		assert.True(t, cbf.Contains(str), "Expected '%s' to be in the Counting Bloom Filter", str)
+	}
+	falsePositives := 0
+	for _, str := range removed {
+		if cbf.Contains(str) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 50)
+}
+
+func TestCountingBloomFilter_Saturation(t *testing.T) {
+	hashFunctions := []hash.Hash32{fnv.New32a()}
+	cbf, err := bloomfilter.NewCountingBloomFilter(1, hashFunctions)
+	require.NoError(t, err)
+
+	// With a single counter, every element shares the same position.
+	for i := 0; i < 300; i++ {
+		cbf.Add("apple")
+	}
+	for i := 0; i < 300; i++ {
+		assert.True(t, cbf.Remove("apple"))
+	}
+
+	// A saturated counter is never decremented, so it cannot underflow.
+	assert.True(t, cbf.Contains("apple"))
+	assert.True(t, cbf.Contains("banana"))
+}
+
+func TestCountingBloomFilter_ToBloomFilter(t *testing.T) {
+	cbf, err := bloomfilter.NewCountingBloomFilterWithEstimates(1000, 0.01)
+	require.NoError(t, err)
+
+	added := generateRandomStrings(500, 10)
+	for _, str := range added {
+		cbf.Add(str)
+	}
+	for _, str := range added[:250] {
+		require.True(t, cbf.Remove(str))
+	}
+
+	bf := cbf.ToBloomFilter()
+	for _, str := range added[250:] {
+		assert.True(t, bf.Contains(str), "Expected '%s' to be in the Bloom Filter", str)
+	}
+	for _, str := range append(added, generateRandomStrings(1000, 12)...) {
+		assert.Equal(t, cbf.Contains(str), bf.Contains(str), "Mismatch for '%s'", str)
+	}
+
+	// The converted filter matches a filter built directly from the remaining elements.
+	direct, err := bloomfilter.NewBloomFilterWithEstimates(1000, 0.01)
+	require.NoError(t, err)
+	for _, str := range added[250:] {
+		direct.Add(str)
+	}
+	assert.True(t, bf.Equals(direct))
+}